@@ -0,0 +1,206 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cassava/repoctl/pkgfile"
+	"github.com/cassava/repoctl/repo"
+)
+
+// dbEntryRegexp matches the top-level directory entries of a pacman
+// repository database tarball: name-version-release, without the
+// architecture/extension suffix that pkgfile.Regexp strips from package
+// filenames.
+var dbEntryRegexp = regexp.MustCompile(`^(.+)-([^-]+-[^-]+)$`)
+
+// filterPkg is everything the criteria below need to know about one
+// package name found on disk.
+type filterPkg struct {
+	Name    string
+	Version string
+	Path    string // newest file on disk for this name
+	Count   int    // number of files on disk sharing Name
+}
+
+// filterCriterion reports whether p matches a named criterion.
+type filterCriterion func(dbPkgs, aurPkgs map[string]string, p *filterPkg) bool
+
+// filterCriteria maps the names documented in Usage to the predicate
+// that implements them. Each can be negated on the command line with a
+// leading "!".
+var filterCriteria = map[string]filterCriterion{
+	"duplicates": func(dbPkgs, aurPkgs map[string]string, p *filterPkg) bool {
+		return p.Count > 1
+	},
+	"pending": func(dbPkgs, aurPkgs map[string]string, p *filterPkg) bool {
+		dbVer, ok := dbPkgs[p.Name]
+		return !ok || dbVer != p.Version
+	},
+	"outdated": func(dbPkgs, aurPkgs map[string]string, p *filterPkg) bool {
+		aurVer, ok := aurPkgs[p.Name]
+		return ok && aurVer != p.Version
+	},
+	"missing": func(dbPkgs, aurPkgs map[string]string, p *filterPkg) bool {
+		_, ok := aurPkgs[p.Name]
+		return !ok
+	},
+	"local": func(dbPkgs, aurPkgs map[string]string, p *filterPkg) bool {
+		return exec.Command("pacman", "-Q", p.Name).Run() == nil
+	},
+	"obsolete": func(dbPkgs, aurPkgs map[string]string, p *filterPkg) bool {
+		return isObsoleteSig(p.Path)
+	},
+}
+
+// aurCriteria are the criteria that need an AUR lookup, which is skipped
+// unless one of them was actually asked for.
+var aurCriteria = map[string]bool{"outdated": true, "missing": true}
+
+// Filter prints the packages in the managed repository that match every
+// criterion in conf.Args (see Usage for the list of criteria), honoring
+// a leading "!" to negate a criterion.
+func Filter(conf *Config) error {
+	pkgs, err := collectFilterPackages(conf)
+	if err != nil {
+		return err
+	}
+
+	dbPkgs, err := readDatabasePackages(conf)
+	if err != nil {
+		return err
+	}
+
+	var aurPkgs map[string]string
+	if needsAUR(conf.Args) {
+		versions := make(map[string]string, len(pkgs))
+		for name, p := range pkgs {
+			versions[name] = p.Version
+		}
+		aurPkgs, err = repo.NewUpdateChecker().Versions(versions)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, p := range pkgs {
+		if !matchesAll(conf.Args, dbPkgs, aurPkgs, p) {
+			continue
+		}
+		if conf.Versioned {
+			fmt.Printf("%s %s\n", name, p.Version)
+		} else {
+			fmt.Println(name)
+		}
+	}
+
+	return nil
+}
+
+// needsAUR reports whether any criterion in criteria requires an AUR
+// lookup.
+func needsAUR(criteria []string) bool {
+	for _, c := range criteria {
+		if aurCriteria[strings.TrimPrefix(c, "!")] {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAll reports whether p satisfies every criterion in criteria.
+// Unrecognized criteria are ignored.
+func matchesAll(criteria []string, dbPkgs, aurPkgs map[string]string, p *filterPkg) bool {
+	for _, c := range criteria {
+		negate := strings.HasPrefix(c, "!")
+		crit, ok := filterCriteria[strings.TrimPrefix(c, "!")]
+		if !ok {
+			continue
+		}
+		if crit(dbPkgs, aurPkgs, p) == negate {
+			return false
+		}
+	}
+	return true
+}
+
+// collectFilterPackages scans conf.path for package files the same way
+// readRepoPackages does, but also keeps the newest file's path and how
+// many files on disk share its name, which "duplicates" and "obsolete"
+// need.
+func collectFilterPackages(conf *Config) (map[string]*filterPkg, error) {
+	files, err := filepath.Glob(filepath.Join(conf.path, "*.pkg.tar.*"))
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make(map[string]*filterPkg)
+	for _, f := range files {
+		name, version, ok := pkgfile.Parse(filepath.Base(f))
+		if !ok {
+			continue
+		}
+
+		p, ok := pkgs[name]
+		if !ok {
+			p = &filterPkg{Name: name}
+			pkgs[name] = p
+		}
+		p.Count++
+		if p.Version == "" || version > p.Version {
+			p.Version = version
+			p.Path = f
+		}
+	}
+
+	return pkgs, nil
+}
+
+// readDatabasePackages lists the name/version of every package recorded
+// in conf.Repository, by listing the top-level "name-version-release/"
+// directory entries of its tar database.
+func readDatabasePackages(conf *Config) (map[string]string, error) {
+	if _, err := os.Stat(conf.Repository); os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+
+	out, err := exec.Command("bsdtar", "-tf", conf.Repository).Output()
+	if err != nil {
+		return nil, fmt.Errorf("reading database: %s", err)
+	}
+
+	pkgs := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		dir := strings.SplitN(line, "/", 2)[0]
+		m := dbEntryRegexp.FindStringSubmatch(dir)
+		if m == nil {
+			continue
+		}
+		pkgs[m[1]] = m[2]
+	}
+
+	return pkgs, nil
+}
+
+// isObsoleteSig reports whether path's detached signature is missing, or
+// older than path itself (stale, e.g. left over from a previous build
+// that produced the same filename).
+func isObsoleteSig(path string) bool {
+	sig, err := os.Stat(path + ".sig")
+	if err != nil {
+		return true
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	return sig.ModTime().Before(info.ModTime())
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/cassava/repoctl/pkgfile"
+	"github.com/cassava/repoctl/repo"
+)
+
+// readRepoPackages scans conf.path for package files and returns a map of
+// package name to the version of its newest file on disk.
+func readRepoPackages(conf *Config) (map[string]string, error) {
+	files, err := filepath.Glob(filepath.Join(conf.path, "*.pkg.tar.*"))
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make(map[string]string)
+	for _, f := range files {
+		name, version, ok := pkgfile.Parse(filepath.Base(f))
+		if !ok {
+			continue
+		}
+		pkgs[name] = version
+	}
+
+	return pkgs, nil
+}
+
+// List prints the packages in the managed repository, honoring the -v
+// and -u display flags in conf. The -p, -d and -l flags do not change
+// its output.
+func List(conf *Config) error {
+	pkgs, err := readRepoPackages(conf)
+	if err != nil {
+		return err
+	}
+
+	var candidates map[string]repo.UpgradeCandidate
+	if conf.Synchronize {
+		candidates, err = checkOutdated(conf, pkgs)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, version := range pkgs {
+		line := name
+		if conf.Versioned {
+			line = fmt.Sprintf("%s %s", name, version)
+		}
+		if c, ok := candidates[name]; ok {
+			line = fmt.Sprintf("%s -> %s", line, c.AURVersion)
+		}
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+// Status prints, if conf.Synchronize is set, a compact count of packages
+// with AUR updates available; otherwise it prints nothing. It does not
+// report pending database changes. Unlike the old serial -u path, the AUR lookup is batched and
+// cached so that status doesn't block on one request per package.
+func Status(conf *Config) error {
+	pkgs, err := readRepoPackages(conf)
+	if err != nil {
+		return err
+	}
+
+	if conf.Synchronize {
+		candidates, err := checkOutdated(conf, pkgs)
+		if err != nil {
+			return err
+		}
+		if len(candidates) > 0 {
+			fmt.Printf("%d updates available\n", len(candidates))
+		} else {
+			fmt.Println("repository is up to date")
+		}
+	}
+
+	return nil
+}
+
+// checkOutdated runs pkgs through a cached repo.UpdateChecker and
+// returns the outdated ones keyed by package name.
+func checkOutdated(conf *Config, pkgs map[string]string) (map[string]repo.UpgradeCandidate, error) {
+	checker := repo.NewUpdateChecker()
+	candidates, err := checker.Check(pkgs)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]repo.UpgradeCandidate, len(candidates))
+	for _, c := range candidates {
+		byName[c.Name] = c
+	}
+	return byName, nil
+}
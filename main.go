@@ -5,10 +5,15 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/goulash/util"
@@ -29,6 +34,21 @@ type IniConfig struct {
 	Repo     string   `toml:"repo"`
 	AddParam []string `toml:"add_params"`
 	RmParam  []string `toml:"rm_params"`
+
+	Backup      bool `toml:"backup"`
+	Interactive bool `toml:"interactive"`
+	Columnate   bool `toml:"columns"`
+	Quiet       bool `toml:"quiet"`
+
+	BuildDir     string   `toml:"build_dir"`
+	MakepkgFlags []string `toml:"makepkg_flags"`
+	AURURL       string   `toml:"aur_url"`
+	IncludeDevel bool     `toml:"include_devel"`
+
+	SignDB       bool   `toml:"sign_db"`
+	SignPackages bool   `toml:"sign_packages"`
+	SigningKey   string `toml:"sign_key"`
+	VerifyOnAdd  bool   `toml:"verify_on_add"`
 }
 
 // Config contains all the configuration flags, variables, and arguments that
@@ -76,6 +96,35 @@ type Config struct {
 	// For this, the files are given the suffix ".bak".
 	Backup bool
 
+	// BuildDir is the directory in which fetched PKGBUILD snapshots are
+	// extracted and built with makepkg before being added to the
+	// repository.
+	BuildDir string
+	// MakepkgFlags are additional flags passed to makepkg when building a
+	// package fetched from AUR.
+	MakepkgFlags []string
+	// AURURL is the base URL of the AUR RPC and cgit snapshot endpoints.
+	AURURL string
+	// IncludeDevel causes VCS/devel packages to be checked and
+	// synchronized as well.
+	IncludeDevel bool
+
+	// SignDB causes the repository database to be signed with gpg.
+	SignDB bool
+	// SignPackages causes every added package to be signed with gpg.
+	SignPackages bool
+	// SigningKey is the gpg key to sign with, or empty for gpg's default.
+	SigningKey string
+	// VerifyOnAdd requires a valid signature on every package before it
+	// is added to the repository.
+	VerifyOnAdd bool
+
+	// Save causes the effective value of every persistable setting (the
+	// fields of IniConfig) to be written back to ConfigFile once the
+	// action completes successfully. List display flags such as -v are
+	// not persisted.
+	Save bool
+
 	// Arguments contains the arguments given on the commandline.
 	Args []string
 }
@@ -123,6 +172,7 @@ Commands available:
                     outdated        packages with newer versions in AUR
                     missing         packages not found in AUR
                     local           packages locally installed
+                    obsolete        packages whose signature is missing or stale
 
   status           Show pending changes to the database and packages that can
                    be updated.
@@ -185,9 +235,162 @@ func readIniInto(path string, conf *Config) error {
 	conf.AddParameters = ini.AddParam
 	conf.RemoveParameters = ini.RmParam
 
+	conf.Backup = conf.Backup || ini.Backup
+	conf.Interactive = conf.Interactive || ini.Interactive
+	conf.Columnate = conf.Columnate || ini.Columnate
+	conf.Quiet = conf.Quiet || ini.Quiet
+
+	if conf.BuildDir == "" {
+		conf.BuildDir = ini.BuildDir
+	}
+	if conf.MakepkgFlags == nil {
+		conf.MakepkgFlags = ini.MakepkgFlags
+	}
+	if conf.AURURL == "" {
+		conf.AURURL = ini.AURURL
+	}
+	conf.IncludeDevel = conf.IncludeDevel || ini.IncludeDevel
+
+	conf.SignDB = conf.SignDB || ini.SignDB
+	conf.SignPackages = conf.SignPackages || ini.SignPackages
+	if conf.SigningKey == "" {
+		conf.SigningKey = ini.SigningKey
+	}
+	conf.VerifyOnAdd = conf.VerifyOnAdd || ini.VerifyOnAdd
+
 	return nil
 }
 
+// writeIniFrom writes the persistable settings in conf back to the TOML
+// configuration file at path, replacing it atomically. Since toml.Encoder
+// has no notion of comments, this re-encodes into a fresh document and
+// then reattaches any comment lines found directly above a matching key
+// in the old file (see commentsByKey); comments that aren't immediately
+// above a recognized key (section banners, trailing notes, etc.) are
+// still lost, so this is a best effort, not a full round-trip.
+func writeIniFrom(path string, conf *Config) error {
+	ini := IniConfig{
+		Repo:     conf.Repository,
+		AddParam: conf.AddParameters,
+		RmParam:  conf.RemoveParameters,
+
+		Backup:      conf.Backup,
+		Interactive: conf.Interactive,
+		Columnate:   conf.Columnate,
+		Quiet:       conf.Quiet,
+
+		BuildDir:     conf.BuildDir,
+		MakepkgFlags: conf.MakepkgFlags,
+		AURURL:       conf.AURURL,
+		IncludeDevel: conf.IncludeDevel,
+
+		SignDB:       conf.SignDB,
+		SignPackages: conf.SignPackages,
+		SigningKey:   conf.SigningKey,
+		VerifyOnAdd:  conf.VerifyOnAdd,
+	}
+
+	header, byKey := commentsByKey(path)
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(ini); err != nil {
+		return err
+	}
+	body := reattachComments(buf.Bytes(), header, byKey)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "repoctl-conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// iniKeyRegexp matches a top-level "key = value" line, capturing key.
+var iniKeyRegexp = regexp.MustCompile(`^([A-Za-z0-9_]+)\s*=`)
+
+// commentsByKey reads the TOML file at path (if it exists) and returns
+// the run of "#"-prefixed comment lines immediately preceding the file's
+// first key (header), and the run of comment lines immediately preceding
+// each subsequent key, indexed by key name.
+func commentsByKey(path string) (header []string, byKey map[string][]string) {
+	byKey = make(map[string][]string)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, byKey
+	}
+	defer f.Close()
+
+	var pending []string
+	sawKey := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "#") {
+			pending = append(pending, line)
+			continue
+		}
+
+		if m := iniKeyRegexp.FindStringSubmatch(trimmed); m != nil {
+			if len(pending) > 0 {
+				if !sawKey {
+					header = pending
+				} else {
+					byKey[m[1]] = pending
+				}
+			}
+			sawKey = true
+			pending = nil
+			continue
+		}
+
+		pending = nil
+	}
+
+	return header, byKey
+}
+
+// reattachComments prepends header above the first line of body, and
+// each byKey[key] comment block above its matching "key = value" line.
+func reattachComments(body []byte, header []string, byKey map[string][]string) []byte {
+	if len(header) == 0 && len(byKey) == 0 {
+		return body
+	}
+
+	var out bytes.Buffer
+	for _, line := range header {
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := iniKeyRegexp.FindStringSubmatch(line); m != nil {
+			for _, comment := range byKey[m[1]] {
+				out.WriteString(comment)
+				out.WriteByte('\n')
+			}
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+
+	return out.Bytes()
+}
+
 // ReadConfig reads a configuration from the command line arguments.
 func ReadConfig() (conf *Config, cmd Action, err error) {
 	var allListOptions bool
@@ -212,6 +415,8 @@ func ReadConfig() (conf *Config, cmd Action, err error) {
 	flag.BoolVarP(&conf.Interactive, "interactive", "i", false, "ask before doing anything destructive")
 	flag.BoolVarP(&conf.Backup, "backup", "b", false, "backup obsolete package files instead of deleting")
 
+	flag.BoolVar(&conf.Save, "save", false, "persist the effective persistable settings to the config file")
+
 	flag.Usage = func() { Usage(nil) }
 	flag.Parse()
 
@@ -275,5 +480,15 @@ func main() {
 		os.Exit(1)
 	}
 
-	cmd(conf)
+	err = cmd(conf)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	if conf.Save {
+		if err := writeIniFrom(conf.ConfigFile, conf); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not save config: %s\n", err)
+		}
+	}
 }
@@ -7,6 +7,7 @@ package main
 import (
 	"os"
 
+	"github.com/cassava/repoctl/repo"
 	"github.com/spf13/cobra"
 )
 
@@ -21,8 +22,28 @@ var ResetCmd = &cobra.Command{
   recreates it by running the update command.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
+		p := ActiveProfile()
+		if cmd.Flags().Changed("sign") {
+			p.SignDB = resetSignDB
+		}
+		if cmd.Flags().Changed("sign-key") {
+			p.SigningKey = resetSigningKey
+		}
+
+		hooks := NewHookRunner(p)
+		payload := HookPayload{Repository: p.Repository}
+		dieOnError(hooks.Run("pre-update", payload))
+
 		err := repo.Reset(repo.PrinterEH(os.Stderr))
 		dieOnError(err)
+
+		if p.SignDB {
+			err = signFile(Repo.DBPath(), p.SigningKey)
+			dieOnError(err)
+			dieOnError(hooks.Run("post-sign", payload))
+		}
+
+		dieOnError(hooks.Run("post-update", payload))
 	},
 }
 
@@ -54,6 +75,27 @@ var AddCmd = &cobra.Command{
 `,
 	Example: `  repoctl add ./fairsplit-1.0.pkg.tar.gz`,
 	Run: func(cmd *cobra.Command, args []string) {
+		p := ActiveProfile()
+		if cmd.Flags().Changed("sign") {
+			p.SignPackages = addSignPackages
+		}
+		if cmd.Flags().Changed("sign-key") {
+			p.SigningKey = addSigningKey
+		}
+		if cmd.Flags().Changed("verify") {
+			p.VerifyOnAdd = addVerify
+		}
+
+		if p.VerifyOnAdd {
+			for _, pkg := range args {
+				dieOnError(verifyFile(pkg))
+			}
+		}
+
+		hooks := NewHookRunner(p)
+		payload := HookPayload{Packages: hookPackagesFromFiles(args), Repository: p.Repository}
+		dieOnError(hooks.Run("pre-add", payload))
+
 		var err error
 		if movePackages {
 			err = Repo.MoveAll(args, repo.PrinterEH(os.Stderr))
@@ -61,6 +103,18 @@ var AddCmd = &cobra.Command{
 			err = Repo.AddAll(args, repo.PrinterEH(os.Stderr))
 		}
 		dieOnError(err)
+
+		names := make([]string, len(payload.Packages))
+		for i, pkg := range payload.Packages {
+			names[i] = pkg.Name
+		}
+		err = signAddedPackages(p, names, Repo.DBPath())
+		dieOnError(err)
+		if p.SignDB || p.SignPackages {
+			dieOnError(hooks.Run("post-sign", payload))
+		}
+
+		dieOnError(hooks.Run("post-add", payload))
 	},
 }
 
@@ -78,7 +132,30 @@ var RemoveCmd = &cobra.Command{
   backup directory.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
+		p := ActiveProfile()
+		hooks := NewHookRunner(p)
+		payload := HookPayload{Packages: hookPackagesFromNames(args), Repository: p.Repository}
+		dieOnError(hooks.Run("pre-remove", payload))
+
+		// Resolve each name to its repo file path before removing it:
+		// once repo.Remove has run, the package file (and thus the
+		// means of finding its ".sig") is gone.
+		var pkgPaths []string
+		for _, pkg := range args {
+			if path, err := Repo.PackagePath(pkg); err == nil {
+				pkgPaths = append(pkgPaths, path)
+			}
+		}
+
 		err := repo.Remove(args, repo.PrinterEH)
 		dieOnError(err)
+
+		for _, path := range pkgPaths {
+			if err := removeOrphanSig(path); err != nil {
+				repo.PrinterEH(os.Stderr)(err)
+			}
+		}
+
+		dieOnError(hooks.Run("post-remove", payload))
 	},
-}
\ No newline at end of file
+}
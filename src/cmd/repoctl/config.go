@@ -0,0 +1,186 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ProfileConfig holds the settings for a single managed repository. A
+// Config can hold any number of these, so that one repoctl invocation can
+// shepherd e.g. a "stable" and a "testing" repo without juggling separate
+// config files.
+type ProfileConfig struct {
+	// Repository is the absolute path to the database. We assume that
+	// this is also where the packages are.
+	Repository string
+	// AddParameters are parameters to add to the repo-add command line.
+	AddParameters []string
+	// RemoveParameters are parameters to add to the repo-remove command
+	// line.
+	RemoveParameters []string
+
+	// Interactive requires confirmation before deleting and changing the
+	// repository database.
+	Interactive bool
+	// Backup causes older packages to be backed up rather than deleted.
+	Backup bool
+
+	// BuildDir is the directory in which fetched PKGBUILD snapshots are
+	// extracted and built with makepkg before being added to the
+	// repository.
+	BuildDir string
+	// MakepkgFlags are additional flags passed to makepkg when building a
+	// package fetched from AUR.
+	MakepkgFlags []string
+	// AURURL is the base URL of the AUR RPC and cgit snapshot endpoints.
+	// It only needs to be changed when testing against a mirror.
+	AURURL string
+	// IncludeDevel causes VCS/devel packages (those ending in -git, -svn,
+	// -hg, -bzr, or -cvs) to be checked and synchronized as well.
+	IncludeDevel bool
+
+	// SignDB causes the repository database to be signed with gpg after
+	// it is rewritten.
+	SignDB bool
+	// SignPackages causes every package added to the repository to be
+	// signed with gpg.
+	SignPackages bool
+	// SigningKey is the gpg key to sign with; if empty, gpg's own default
+	// signing key is used.
+	SigningKey string
+	// VerifyOnAdd requires a valid detached signature to be present for
+	// every package before it is added to the repository.
+	VerifyOnAdd bool
+
+	// Hooks maps event name (pre-add, post-add, pre-remove, post-remove,
+	// pre-update, post-update, post-sign) to a command to run for that
+	// event.
+	Hooks map[string]string
+	// HooksDir, if set, is scanned for executable files named
+	// "<event>.d/*" for every event, in addition to Hooks.
+	HooksDir string
+	// StrictHooks causes a failing post-* hook to fail the command that
+	// triggered it, instead of just being logged.
+	StrictHooks bool
+}
+
+// Config contains the configuration flags, variables, and arguments that
+// are needed by the cobra-based commands in this package.
+type Config struct {
+	// Profiles maps profile name to its settings. A single-repo TOML
+	// config (the historical format) is loaded as the implicit profile
+	// "default".
+	Profiles map[string]*ProfileConfig
+	// DefaultProfile is the profile used when --profile/-R is not given.
+	DefaultProfile string
+}
+
+// Conf is the configuration used by the commands in this package.
+var Conf = &Config{
+	Profiles: map[string]*ProfileConfig{
+		"default": {
+			BuildDir: "/tmp/repoctl",
+			AURURL:   "https://aur.archlinux.org",
+		},
+	},
+	DefaultProfile: "default",
+}
+
+// profileFlagName, when non-empty, overrides Conf.DefaultProfile for the
+// current invocation. It is bound to the --profile/-R persistent flag.
+var profileFlagName string
+
+// ActiveProfile returns the ProfileConfig for the profile selected on the
+// command line (--profile/-R), or Conf.DefaultProfile if none was given.
+// If the named profile does not exist, an empty one is created so that
+// commands can still run against explicit flags alone.
+func ActiveProfile() *ProfileConfig {
+	name := profileFlagName
+	if name == "" {
+		name = Conf.DefaultProfile
+	}
+
+	p, ok := Conf.Profiles[name]
+	if !ok {
+		p = &ProfileConfig{}
+		Conf.Profiles[name] = p
+	}
+	return p
+}
+
+// tomlConfig is the on-disk representation of the config file. The
+// top-level fields are the historical single-repo format, which is
+// loaded as the implicit profile "default" for backwards compatibility;
+// [profiles.<name>] sections describe any additional profiles.
+type tomlConfig struct {
+	Repo     string   `toml:"repo"`
+	AddParam []string `toml:"add_params"`
+	RmParam  []string `toml:"rm_params"`
+
+	DefaultProfile string                    `toml:"default_profile"`
+	Profiles       map[string]*ProfileConfig `toml:"profiles"`
+}
+
+// LoadProfiles reads path and populates Conf.Profiles and
+// Conf.DefaultProfile from it. The legacy single-repo format (top-level
+// repo/add_params/rm_params, no [profiles] section) continues to parse,
+// becoming the profile named "default".
+func LoadProfiles(path string) error {
+	var raw tomlConfig
+	if _, err := toml.DecodeFile(path, &raw); err != nil {
+		return err
+	}
+
+	if raw.Profiles != nil {
+		Conf.Profiles = raw.Profiles
+	}
+	if raw.Repo != "" {
+		def, ok := Conf.Profiles["default"]
+		if !ok {
+			def = &ProfileConfig{}
+			Conf.Profiles["default"] = def
+		}
+		def.Repository = raw.Repo
+		def.AddParameters = raw.AddParam
+		def.RemoveParameters = raw.RmParam
+	}
+	if raw.DefaultProfile != "" {
+		Conf.DefaultProfile = raw.DefaultProfile
+	}
+
+	return nil
+}
+
+// SaveProfiles writes Conf back out to path in the [profiles.<name>]
+// format, replacing it atomically. The legacy top-level repo/add_params/
+// rm_params fields are left empty on write; once a config has been
+// resaved, its profiles live exclusively under [profiles].
+func SaveProfiles(path string) error {
+	raw := tomlConfig{
+		DefaultProfile: Conf.DefaultProfile,
+		Profiles:       Conf.Profiles,
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "repoctl-conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	enc := toml.NewEncoder(tmp)
+	if err := enc.Encode(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cassava/repoctl/repo"
+	"github.com/spf13/cobra"
+)
+
+var fsckFix bool
+
+func init() {
+	FsckCmd.Flags().BoolVar(&fsckFix, "fix", false, "attempt to repair detected problems")
+}
+
+// FsckCmd audits the repository database for corruption and drift
+// without mutating it, unless --fix is given.
+var FsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "check the repository database for corruption and drift",
+	Long: `Audit the repository database for corruption and drift.
+
+  This checks that every package referenced by the database exists on
+  disk with a matching size and checksum, that every indexed checksum is
+  still correct, that no untracked or missing package files exist, and
+  that no package has multiple versions lying around. Nothing is changed
+  unless --fix is given, in which case detected problems are routed
+  through the existing update/add/remove machinery.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		issues, err := repo.Fsck(Repo, repo.FsckOptions{})
+		dieOnError(err)
+
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		if len(issues) == 0 {
+			fmt.Println("repository is clean")
+			return
+		}
+
+		if fsckFix {
+			p := ActiveProfile()
+			err = fixIssues(issues, NewHookRunner(p), p.Repository)
+			dieOnError(err)
+		}
+	},
+}
+
+// fixIssues routes detected Issues through the existing repo.Update,
+// repo.AddAll, and repo.Remove paths, firing the same pre-/post- hooks
+// those operations trigger when run directly through add/remove/reset,
+// so a --fix repair isn't silently invisible to hooks.
+func fixIssues(issues []repo.Issue, hooks *HookRunner, repository string) error {
+	eh := repo.PrinterEH(os.Stderr)
+	for _, issue := range issues {
+		switch issue.Message {
+		case "missing package file":
+			payload := HookPayload{Packages: hookPackagesFromNames([]string{issue.Package}), Repository: repository}
+			if err := hooks.Run("pre-remove", payload); err != nil {
+				return err
+			}
+			if err := repo.Remove([]string{issue.Package}, eh); err != nil {
+				return err
+			}
+			if err := hooks.Run("post-remove", payload); err != nil {
+				return err
+			}
+		case "package file not indexed in database":
+			payload := HookPayload{Packages: hookPackagesFromFiles([]string{issue.Path}), Repository: repository}
+			if err := hooks.Run("pre-add", payload); err != nil {
+				return err
+			}
+			if err := Repo.AddAll([]string{issue.Path}, eh); err != nil {
+				return err
+			}
+			if err := hooks.Run("post-add", payload); err != nil {
+				return err
+			}
+		default:
+			payload := HookPayload{Repository: repository}
+			if err := hooks.Run("pre-update", payload); err != nil {
+				return err
+			}
+			if err := repo.Update(eh); err != nil {
+				return err
+			}
+			if err := hooks.Run("post-update", payload); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
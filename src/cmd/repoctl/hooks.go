@@ -0,0 +1,152 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cassava/repoctl/pkgfile"
+	"github.com/cassava/repoctl/repo"
+)
+
+// hookPackagesFromFiles builds the HookPackage list for a set of package
+// file paths, as used by the add hooks.
+func hookPackagesFromFiles(paths []string) []HookPackage {
+	pkgs := make([]HookPackage, 0, len(paths))
+	for _, p := range paths {
+		name, version, ok := pkgfile.Parse(filepath.Base(p))
+		if !ok {
+			name, version = filepath.Base(p), ""
+		}
+		pkgs = append(pkgs, HookPackage{Name: name, Version: version, Path: p})
+	}
+	return pkgs
+}
+
+// hookPackagesFromNames builds the HookPackage list for a set of bare
+// package names, as used by the remove hooks.
+func hookPackagesFromNames(names []string) []HookPackage {
+	pkgs := make([]HookPackage, 0, len(names))
+	for _, name := range names {
+		pkgs = append(pkgs, HookPackage{Name: name})
+	}
+	return pkgs
+}
+
+// HookPackage describes a single package involved in a hook event.
+type HookPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+}
+
+// HookPayload is the JSON document written to a hook's stdin.
+type HookPayload struct {
+	Event      string        `json:"event"`
+	Packages   []HookPackage `json:"packages"`
+	Repository string        `json:"repository"`
+}
+
+// HookRunner runs the commands and scripts configured for repository
+// events. pre-* hooks that fail abort the action that triggered them;
+// post-* hooks that fail are only logged, unless Strict is set.
+type HookRunner struct {
+	Hooks    map[string]string
+	HooksDir string
+	Strict   bool
+}
+
+// NewHookRunner builds a HookRunner from a profile's hook configuration.
+func NewHookRunner(p *ProfileConfig) *HookRunner {
+	return &HookRunner{
+		Hooks:    p.Hooks,
+		HooksDir: p.HooksDir,
+		Strict:   p.StrictHooks,
+	}
+}
+
+// Run invokes every hook configured for event (the one in Hooks, plus
+// every executable file in HooksDir/<event>.d/), passing payload as JSON
+// on stdin. A failing pre-* hook aborts immediately and its error is
+// returned so that the caller can propagate it through dieOnError; a
+// failing post-* hook is logged via repo.PrinterEH and otherwise
+// swallowed unless h.Strict is set.
+func (h *HookRunner) Run(event string, payload HookPayload) error {
+	payload.Event = event
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("hook %s: %s", event, err)
+	}
+
+	isPre := strings.HasPrefix(event, "pre-")
+	eh := repo.PrinterEH(os.Stderr)
+
+	for _, command := range h.commandsFor(event) {
+		if err := runHook(command, body); err != nil {
+			err = fmt.Errorf("hook %s (%s): %s", event, command, err)
+			if isPre {
+				return err
+			}
+			eh(err)
+			if h.Strict {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// commandsFor returns the configured command for event (if any) followed
+// by every executable script found in HooksDir/<event>.d/, sorted by
+// name.
+func (h *HookRunner) commandsFor(event string) []string {
+	var commands []string
+	if cmd, ok := h.Hooks[event]; ok && cmd != "" {
+		commands = append(commands, cmd)
+	}
+
+	if h.HooksDir == "" {
+		return commands
+	}
+
+	entries, err := os.ReadDir(filepath.Join(h.HooksDir, event+".d"))
+	if err != nil {
+		return commands
+	}
+
+	var scripts []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Mode()&0111 == 0 {
+			continue
+		}
+		scripts = append(scripts, filepath.Join(h.HooksDir, event+".d", entry.Name()))
+	}
+	sort.Strings(scripts)
+
+	return append(commands, scripts...)
+}
+
+// runHook runs command with body on stdin via "sh -c", with its output
+// going to repoctl's own stdout and stderr.
+func runHook(command string, body []byte) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
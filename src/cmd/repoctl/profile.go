@@ -0,0 +1,118 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// ProfileCmd manages the set of repository profiles in the config file.
+var ProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "manage repository profiles",
+	Long: `Manage the set of repository profiles available to repoctl.
+
+  A profile bundles a repository path together with the settings that
+  apply to it (add/remove parameters, build directory, signing key, and
+  so on), so that a single repoctl invocation can be pointed at any one
+  of several repositories with --profile/-R.
+`,
+	// Managing profiles doesn't need a repository open (and the active
+	// profile may not even have a valid one yet), so override RootCmd's
+	// PersistentPreRunE and only load what's on disk.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := LoadProfiles(configFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	ProfileCmd.AddCommand(profileListCmd, profileAddCmd, profileRemoveCmd, profileUseCmd)
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list configured profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		names := make([]string, 0, len(Conf.Profiles))
+		for name := range Conf.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			marker := "  "
+			if name == Conf.DefaultProfile {
+				marker = "* "
+			}
+			fmt.Printf("%s%s\t%s\n", marker, name, Conf.Profiles[name].Repository)
+		}
+	},
+}
+
+var profileAddCmd = &cobra.Command{
+	Use:   "add <name> <repository>",
+	Short: "add a new profile",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			dieOnError(fmt.Errorf("usage: repoctl profile add <name> <repository>"))
+		}
+		name, repository := args[0], args[1]
+
+		if _, ok := Conf.Profiles[name]; ok {
+			dieOnError(fmt.Errorf("profile %q already exists", name))
+		}
+		Conf.Profiles[name] = &ProfileConfig{Repository: repository}
+
+		dieOnError(SaveProfiles(configFile))
+	},
+}
+
+var profileRemoveCmd = &cobra.Command{
+	Use:     "remove <name>",
+	Aliases: []string{"rm"},
+	Short:   "remove a profile",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			dieOnError(fmt.Errorf("usage: repoctl profile remove <name>"))
+		}
+		name := args[0]
+
+		if name == Conf.DefaultProfile {
+			dieOnError(fmt.Errorf("cannot remove the default profile %q", name))
+		}
+		if _, ok := Conf.Profiles[name]; !ok {
+			fmt.Fprintf(os.Stderr, "warning: profile %q does not exist\n", name)
+			return
+		}
+		delete(Conf.Profiles, name)
+
+		dieOnError(SaveProfiles(configFile))
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "set the default profile",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			dieOnError(fmt.Errorf("usage: repoctl profile use <name>"))
+		}
+		name := args[0]
+
+		if _, ok := Conf.Profiles[name]; !ok {
+			dieOnError(fmt.Errorf("profile %q does not exist", name))
+		}
+		Conf.DefaultProfile = name
+
+		dieOnError(SaveProfiles(configFile))
+	},
+}
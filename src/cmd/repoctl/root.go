@@ -0,0 +1,54 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path"
+
+	"github.com/cassava/repoctl/repo"
+	"github.com/spf13/cobra"
+)
+
+// Repo is the repository that the active profile points at. It is
+// (re-)opened by RootCmd's PersistentPreRunE before every command runs.
+var Repo *repo.Repo
+
+// configFile is the TOML file that profiles are loaded from and, via
+// "repoctl profile add|remove|use", saved back to.
+var configFile = path.Join(os.Getenv("HOME"), ".repoctl.conf")
+
+// RootCmd is the base command for repoctl; every other command in this
+// package is attached to it.
+var RootCmd = &cobra.Command{
+	Use:   "repoctl",
+	Short: "manage local pacman repositories",
+	// PersistentPreRunE loads the profiles from configFile, resolves the
+	// active one (as selected by --profile/-R, or Conf.DefaultProfile
+	// otherwise), and opens its repository, so that every subcommand sees
+	// the right Repo without having to resolve the profile itself.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := LoadProfiles(configFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		p := ActiveProfile()
+
+		r, err := repo.New(p.Repository)
+		if err != nil {
+			return err
+		}
+		Repo = r
+
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.PersistentFlags().StringVar(&configFile, "config", configFile, "configuration file to load profiles from")
+	RootCmd.PersistentFlags().StringVarP(&profileFlagName, "profile", "R", "", "profile to use (see `repoctl profile list`)")
+
+	RootCmd.AddCommand(AddCmd, RemoveCmd, ResetCmd, SyncCmd, FsckCmd, ProfileCmd)
+}
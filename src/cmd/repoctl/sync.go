@@ -0,0 +1,194 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cassava/repoctl/repo"
+	"github.com/spf13/cobra"
+)
+
+// outdatedPkg is a type alias for repo.Outdated, kept so the rest of
+// this file (and any other cobra command built on top of it) doesn't
+// need to spell out the repo-qualified name.
+type outdatedPkg = repo.Outdated
+
+var (
+	syncBuildDir     string
+	syncMakepkgFlags []string
+	syncAURURL       string
+	syncIncludeDevel bool
+	syncInteractive  bool
+	syncBackup       bool
+)
+
+func init() {
+	SyncCmd.Flags().StringVar(&syncBuildDir, "build-dir", "", "directory to build AUR packages in (overrides profile)")
+	SyncCmd.Flags().StringSliceVar(&syncMakepkgFlags, "makepkg-flags", nil, "additional flags to pass to makepkg (overrides profile)")
+	SyncCmd.Flags().StringVar(&syncAURURL, "aur-url", "", "base URL of the AUR (overrides profile)")
+	SyncCmd.Flags().BoolVar(&syncIncludeDevel, "devel", false, "also check and rebuild VCS/devel packages")
+	SyncCmd.Flags().BoolVarP(&syncInteractive, "interactive", "i", false, "confirm each package before rebuilding (overrides profile)")
+	SyncCmd.Flags().BoolVarP(&syncBackup, "backup", "b", false, "backup obsolete package files instead of deleting (overrides profile)")
+}
+
+// SyncCmd synchronizes the repository with AUR: every managed package is
+// checked against AUR, and packages with a newer version available are
+// offered for download, build, and addition to the repository.
+var SyncCmd = &cobra.Command{
+	Use:     "sync",
+	Aliases: []string{"-Syu", "upgrade"},
+	Short:   "synchronize repository packages with AUR",
+	Long: `Check every package in the repository against AUR and build any that
+have a newer version available.
+
+  For every package currently in the managed repository, repoctl queries
+  the AUR RPC for the latest version, and presents an interactive
+  checklist of outdated packages (honoring --interactive). For every
+  package selected, the PKGBUILD snapshot is downloaded, built with
+  makepkg in --build-dir, and the resulting package is added to the
+  repository with the same semantics as "repoctl add".
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		p := ActiveProfile()
+		if cmd.Flags().Changed("build-dir") {
+			p.BuildDir = syncBuildDir
+		}
+		if cmd.Flags().Changed("makepkg-flags") {
+			p.MakepkgFlags = syncMakepkgFlags
+		}
+		if cmd.Flags().Changed("aur-url") {
+			p.AURURL = syncAURURL
+		}
+		if cmd.Flags().Changed("devel") {
+			p.IncludeDevel = syncIncludeDevel
+		}
+		if cmd.Flags().Changed("interactive") {
+			p.Interactive = syncInteractive
+		}
+		if cmd.Flags().Changed("backup") {
+			p.Backup = syncBackup
+		}
+		if p.AURURL == "" {
+			p.AURURL = "https://aur.archlinux.org"
+		}
+
+		pkgs, err := Repo.Packages()
+		dieOnError(err)
+
+		candidates, err := Sync(Repo, pkgs, p)
+		dieOnError(err)
+
+		if len(candidates) == 0 {
+			fmt.Println("nothing to do")
+			return
+		}
+
+		selected := candidates
+		if p.Interactive {
+			selected = confirmOutdated(candidates)
+		}
+
+		built, err := buildAll(selected, p)
+		dieOnError(err)
+
+		if len(built) > 0 {
+			hooks := NewHookRunner(p)
+			payload := HookPayload{Packages: hookPackagesFromFiles(built), Repository: p.Repository}
+			dieOnError(hooks.Run("pre-add", payload))
+
+			err = Repo.AddAll(built, repo.PrinterEH(os.Stderr))
+			dieOnError(err)
+
+			dieOnError(hooks.Run("post-add", payload))
+		}
+	},
+}
+
+// Sync checks pkgs against AUR (and, if conf.IncludeDevel is set, reports
+// VCS/devel packages unconditionally) and returns the set of packages that
+// are outdated; it does not build them. The AUR-diff itself is repo.Sync; this just adapts a
+// ProfileConfig into a repo.SyncOptions for library callers that don't
+// go through cobra.
+func Sync(r *repo.Repo, pkgs []string, conf *ProfileConfig) ([]outdatedPkg, error) {
+	return repo.Sync(r, pkgs, repo.SyncOptions{
+		AURURL:       conf.AURURL,
+		IncludeDevel: conf.IncludeDevel,
+	})
+}
+
+// confirmOutdated presents an interactive checklist of outdated packages
+// and returns the ones the user selected.
+func confirmOutdated(pkgs []outdatedPkg) []outdatedPkg {
+	var selected []outdatedPkg
+	for _, p := range pkgs {
+		fmt.Printf("==> Sync %s (%s -> %s)? [Y/n] ", p.Name, p.LocalVersion, p.RemoteVersion)
+		var answer string
+		fmt.Scanln(&answer)
+		if answer == "" || strings.EqualFold(answer, "y") {
+			selected = append(selected, p)
+		}
+	}
+	return selected
+}
+
+// buildAll fetches the PKGBUILD snapshot and builds each outdated package
+// with makepkg in conf.BuildDir, returning the paths of the packages that
+// built successfully.
+func buildAll(pkgs []outdatedPkg, conf *ProfileConfig) ([]string, error) {
+	var built []string
+	for _, p := range pkgs {
+		dir := filepath.Join(conf.BuildDir, p.Name)
+		if err := fetchSnapshot(p.Name, conf.AURURL, conf.BuildDir); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %s\n", p.Name, err)
+			continue
+		}
+
+		args := append([]string{"--noconfirm", "-f"}, conf.MakepkgFlags...)
+		cmd := exec.Command("makepkg", args...)
+		cmd.Dir = dir
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: makepkg: %s\n", p.Name, err)
+			continue
+		}
+
+		pkgfiles, err := filepath.Glob(filepath.Join(dir, "*.pkg.tar.*"))
+		if err != nil || len(pkgfiles) == 0 {
+			fmt.Fprintf(os.Stderr, "warning: %s: no package file produced\n", p.Name)
+			continue
+		}
+		built = append(built, pkgfiles...)
+	}
+	return built, nil
+}
+
+// fetchSnapshot downloads and extracts the AUR cgit snapshot for pkg into
+// destDir/pkg.
+func fetchSnapshot(pkg, base, destDir string) error {
+	snapURL := fmt.Sprintf("%s/cgit/aur.git/snapshot/%s.tar.gz", base, pkg)
+	resp, err := http.Get(snapURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching snapshot: %s", resp.Status)
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("tar", "-xz", "-C", destDir)
+	cmd.Stdin = resp.Body
+	return cmd.Run()
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+// Package aur performs AUR RPC v5 "info" queries. It exists so that
+// repoctl's update checker and its sync command share one HTTP/JSON
+// client instead of carrying their own copies of it.
+package aur
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// BatchSize is the maximum number of arg[] parameters a caller should
+// put into a single Query, to stay well under the AUR server's URI
+// length limit.
+const BatchSize = 150
+
+// Info is the subset of the AUR RPC v5 "info" response that repoctl
+// cares about.
+type Info struct {
+	Name        string `json:"Name"`
+	Version     string `json:"Version"`
+	PackageBase string `json:"PackageBase"`
+}
+
+type infoResponse struct {
+	Results []Info `json:"results"`
+}
+
+// Query performs a single "type=info" RPC v5 request against base (e.g.
+// "https://aur.archlinux.org") for names, returning one Info per name
+// AUR has a listing for. Callers with more than BatchSize names must
+// split the request themselves.
+func Query(base string, names []string) ([]Info, error) {
+	v := url.Values{}
+	v.Set("v", "5")
+	v.Set("type", "info")
+	for _, name := range names {
+		v.Add("arg[]", name)
+	}
+
+	resp, err := http.Get(base + "/rpc/?" + v.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("aur: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var info infoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("aur: decoding response: %s", err)
+	}
+
+	return info.Results, nil
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+// Package pkgfile parses pacman package filenames. It exists so that
+// repoctl's legacy flag-based entry point and its cobra-based commands
+// don't each carry their own copy of the same filename layout.
+package pkgfile
+
+import "regexp"
+
+// Regexp matches the standard pacman package filename layout:
+// name-version-release-arch.pkg.tar.<ext>
+var Regexp = regexp.MustCompile(`^(.+)-([^-]+-[^-]+)-(?:any|x86_64|i686)\.pkg\.tar\.\w+$`)
+
+// Parse splits filename into name and version-release. ok is false if
+// filename doesn't match the standard layout.
+func Parse(filename string) (name, version string, ok bool) {
+	m := Regexp.FindStringSubmatch(filename)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Severity classifies how serious an Issue found by Fsck is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Issue describes a single integrity problem found while auditing a
+// repository.
+type Issue struct {
+	Severity Severity
+	Package  string
+	// Path is the on-disk package file the issue concerns, if any; it is
+	// set whenever a caller needs to act on the file directly (e.g. to
+	// add it), since Package is a name, not a path.
+	Path    string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Package, i.Message)
+}
+
+// FsckOptions configures Fsck.
+type FsckOptions struct{}
+
+// Fsck audits r for corruption and drift and returns the issues found. It
+// does not modify the repository; see FsckCmd's --fix in cmd/repoctl for
+// a caller that repairs them.
+func Fsck(r *Repo, opts FsckOptions) ([]Issue, error) {
+	var issues []Issue
+
+	dbPkgs, err := r.Packages()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(dbPkgs))
+	for _, name := range dbPkgs {
+		seen[name] = true
+
+		pkgPath, err := r.PackagePath(name)
+		if err != nil {
+			issues = append(issues, Issue{SeverityError, name, "", "missing package file"})
+			continue
+		}
+
+		info, err := os.Stat(pkgPath)
+		if err != nil {
+			issues = append(issues, Issue{SeverityError, name, pkgPath, "missing package file"})
+			continue
+		}
+
+		if recorded, err := r.RecordedChecksum(name); err == nil {
+			sum, err := sha256File(pkgPath)
+			if err != nil {
+				issues = append(issues, Issue{SeverityError, name, pkgPath, "could not compute %SHA256SUM%"})
+			} else if sum != recorded {
+				issues = append(issues, Issue{SeverityError, name, pkgPath, "%SHA256SUM% does not match database"})
+			}
+		}
+
+		if recorded, err := r.RecordedMD5Sum(name); err == nil {
+			sum, err := md5File(pkgPath)
+			if err != nil {
+				issues = append(issues, Issue{SeverityError, name, pkgPath, "could not compute %MD5SUM%"})
+			} else if sum != recorded {
+				issues = append(issues, Issue{SeverityError, name, pkgPath, "%MD5SUM% does not match database"})
+			}
+		}
+
+		if recordedSize, err := r.RecordedSize(name); err == nil && recordedSize != info.Size() {
+			issues = append(issues, Issue{SeverityError, name, pkgPath, "%CSIZE% does not match database"})
+		}
+
+		if recordedSig, err := r.RecordedPGPSig(name); err == nil && recordedSig != "" {
+			if _, err := os.Stat(pkgPath + ".sig"); err != nil {
+				issues = append(issues, Issue{SeverityError, name, pkgPath, "%PGPSIG% recorded but signature file is missing"})
+			}
+		}
+	}
+
+	onDisk, err := filepath.Glob(filepath.Join(r.Path(), "*.pkg.tar.*"))
+	if err != nil {
+		return nil, err
+	}
+
+	versionsOf := make(map[string][]string)
+	for _, f := range onDisk {
+		name, err := r.NameFromFile(f)
+		if err != nil {
+			continue
+		}
+		versionsOf[name] = append(versionsOf[name], f)
+		if !seen[name] {
+			issues = append(issues, Issue{SeverityWarning, name, f, "package file not indexed in database"})
+		}
+	}
+
+	for name, files := range versionsOf {
+		if len(files) > 1 {
+			issues = append(issues, Issue{SeverityWarning, name, "", fmt.Sprintf("%d package files on disk", len(files))})
+		}
+	}
+
+	return issues, nil
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func md5File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
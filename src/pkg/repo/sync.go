@@ -0,0 +1,174 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cassava/repoctl/aur"
+)
+
+// Outdated describes a package in the repository that has a newer
+// version available on AUR.
+type Outdated struct {
+	Name          string
+	LocalVersion  string
+	RemoteVersion string
+}
+
+// SyncOptions controls Sync's AUR lookup and devel-package handling.
+type SyncOptions struct {
+	// AURURL is the base URL of the AUR RPC endpoint.
+	AURURL string
+	// IncludeDevel causes VCS/devel packages (those ending in -git,
+	// -svn, -hg, -bzr, or -cvs) to be reported unconditionally, since
+	// their version can't be meaningfully compared against AUR.
+	IncludeDevel bool
+}
+
+// Sync checks pkgs against AUR (and, if opts.IncludeDevel is set,
+// reports VCS/devel packages unconditionally) and returns the set of
+// packages that are outdated. It is a plain function of its arguments,
+// so library callers can drive it directly without going through
+// cobra or a cmd/repoctl ProfileConfig.
+//
+// Internally this mirrors yay's simplified upList(): one goroutine walks
+// r to get each package's locally installed version, one performs the
+// batched AUR multi-info query, and one checks for VCS/devel packages;
+// the three result sets are merged, with devel packages taking priority
+// over an AUR diff so a -git package is never reported twice.
+func Sync(r *Repo, pkgs []string, opts SyncOptions) ([]Outdated, error) {
+	var wg sync.WaitGroup
+	var localVersions map[string]string
+	var aurResults []aur.Info
+	var develResults []string
+	var dbErr, aurErr, develErr error
+
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		localVersions, dbErr = readLocalVersions(r, pkgs)
+	}()
+	go func() {
+		defer wg.Done()
+		aurResults, aurErr = queryAURInfo(pkgs, opts.AURURL)
+	}()
+	go func() {
+		defer wg.Done()
+		if opts.IncludeDevel {
+			develResults = filterDevel(pkgs)
+		}
+	}()
+	wg.Wait()
+
+	if err := firstOf(dbErr, aurErr, develErr); err != nil {
+		return nil, err
+	}
+
+	develSet := make(map[string]bool, len(develResults))
+	for _, name := range develResults {
+		develSet[name] = true
+	}
+
+	byName := make(map[string]aur.Info, len(aurResults))
+	for _, res := range aurResults {
+		byName[res.Name] = res
+	}
+
+	var out []Outdated
+	for _, name := range pkgs {
+		if develSet[name] {
+			continue
+		}
+		localVer, ok := localVersions[name]
+		if !ok {
+			continue
+		}
+		if info, ok := byName[name]; ok && vercmp(info.Version, localVer) > 0 {
+			out = append(out, Outdated{Name: name, LocalVersion: localVer, RemoteVersion: info.Version})
+		}
+	}
+	for _, name := range develResults {
+		out = append(out, Outdated{Name: name, LocalVersion: "devel", RemoteVersion: "devel"})
+	}
+
+	return out, nil
+}
+
+// readLocalVersions looks up the currently installed version of every
+// name in pkgs within r.
+func readLocalVersions(r *Repo, pkgs []string) (map[string]string, error) {
+	versions := make(map[string]string, len(pkgs))
+	for _, name := range pkgs {
+		version, err := r.Version(name)
+		if err != nil {
+			continue
+		}
+		versions[name] = version
+	}
+	return versions, nil
+}
+
+// firstOf returns the first non-nil error in errs, combining all non-nil
+// errors into its message if there is more than one.
+func firstOf(errs ...error) error {
+	var present []error
+	for _, err := range errs {
+		if err != nil {
+			present = append(present, err)
+		}
+	}
+	switch len(present) {
+	case 0:
+		return nil
+	case 1:
+		return present[0]
+	default:
+		msgs := make([]string, len(present))
+		for i, err := range present {
+			msgs[i] = err.Error()
+		}
+		return fmt.Errorf("sync: %s", strings.Join(msgs, "; "))
+	}
+}
+
+// queryAURInfo performs batched "type=info" RPC v5 queries against AUR,
+// keeping the number of arg[] parameters per request low enough to stay
+// under typical URI length limits.
+func queryAURInfo(pkgs []string, base string) ([]aur.Info, error) {
+	var results []aur.Info
+	for i := 0; i < len(pkgs); i += aur.BatchSize {
+		end := i + aur.BatchSize
+		if end > len(pkgs) {
+			end = len(pkgs)
+		}
+
+		batch, err := aur.Query(base, pkgs[i:end])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, batch...)
+	}
+
+	return results, nil
+}
+
+// filterDevel returns the subset of pkgs that look like VCS/devel
+// packages, which cannot be version-checked against AUR and must always
+// be considered for a rebuild.
+func filterDevel(pkgs []string) []string {
+	var devel []string
+	for _, p := range pkgs {
+		for _, suffix := range []string{"-git", "-svn", "-hg", "-bzr", "-cvs"} {
+			if strings.HasSuffix(p, suffix) {
+				devel = append(devel, p)
+				break
+			}
+		}
+	}
+	return devel
+}
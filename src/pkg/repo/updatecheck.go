@@ -0,0 +1,388 @@
+// Copyright (c) 2015, Ben Morgan. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cassava/repoctl/aur"
+)
+
+// updateCheckWorkers is the number of concurrent workers used to fan out
+// batched AUR RPC requests.
+const updateCheckWorkers = 4
+
+// UpgradeCandidate describes a package whose AUR version is newer than
+// what is currently in the repository.
+type UpgradeCandidate struct {
+	Name         string
+	LocalVersion string
+	AURVersion   string
+	// LeftDiff and RightDiff are the common-prefix-trimmed halves of
+	// LocalVersion and AURVersion respectively, so that a UI can
+	// colorize just the part of the version string that changed.
+	LeftDiff  string
+	RightDiff string
+}
+
+// UpdateChecker checks packages against AUR for available updates. It
+// batches RPC requests, fans them out across a bounded worker pool, and
+// caches results on disk for TTL.
+type UpdateChecker struct {
+	AURURL    string
+	TTL       time.Duration
+	CachePath string
+}
+
+// NewUpdateChecker creates an UpdateChecker with the default AUR URL, a
+// 1 hour cache TTL, and a cache file under $XDG_CACHE_HOME/repoctl.
+func NewUpdateChecker() *UpdateChecker {
+	return &UpdateChecker{
+		AURURL:    "https://aur.archlinux.org",
+		TTL:       time.Hour,
+		CachePath: defaultCachePath(),
+	}
+}
+
+func defaultCachePath() string {
+	cache := os.Getenv("XDG_CACHE_HOME")
+	if cache == "" {
+		cache = path.Join(os.Getenv("HOME"), ".cache")
+	}
+	return path.Join(cache, "repoctl", "aur.json")
+}
+
+type cacheEntry struct {
+	Version   string    `json:"version"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Versions returns the AUR version of every name in pkgs that AUR has a
+// listing for; a name with no AUR listing is simply absent from the
+// result, which is what Filter's "missing" criterion keys off of.
+func (u *UpdateChecker) Versions(pkgs map[string]string) (map[string]string, error) {
+	cache := u.loadCache()
+
+	names := make([]string, 0, len(pkgs))
+	for name := range pkgs {
+		if entry, ok := cache[name]; ok && time.Since(entry.FetchedAt) < u.TTL {
+			continue
+		}
+		names = append(names, name)
+	}
+
+	fresh, err := u.fetch(names)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, name := range names {
+		if _, ok := fresh[name]; !ok {
+			delete(cache, name)
+		}
+	}
+	for name, version := range fresh {
+		cache[name] = cacheEntry{Version: version, FetchedAt: now}
+	}
+	u.saveCache(cache)
+
+	versions := make(map[string]string, len(pkgs))
+	for name := range pkgs {
+		if entry, ok := cache[name]; ok {
+			versions[name] = entry.Version
+		}
+	}
+	return versions, nil
+}
+
+// Check takes a map of package name to locally installed version, and
+// returns the subset that have a newer version available on AUR.
+func (u *UpdateChecker) Check(pkgs map[string]string) ([]UpgradeCandidate, error) {
+	versions, err := u.Versions(pkgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []UpgradeCandidate
+	for name, localVer := range pkgs {
+		aurVer, ok := versions[name]
+		if !ok || vercmp(aurVer, localVer) <= 0 {
+			continue
+		}
+		left, right := getVersionDiff(localVer, aurVer)
+		candidates = append(candidates, UpgradeCandidate{
+			Name:         name,
+			LocalVersion: localVer,
+			AURVersion:   aurVer,
+			LeftDiff:     left,
+			RightDiff:    right,
+		})
+	}
+
+	return candidates, nil
+}
+
+// fetch performs the batched, worker-pooled AUR RPC "info" queries for
+// names and returns a map of package name to AUR version.
+func (u *UpdateChecker) fetch(names []string) (map[string]string, error) {
+	result := make(map[string]string, len(names))
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	var batches [][]string
+	for i := 0; i < len(names); i += aur.BatchSize {
+		end := i + aur.BatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+		batches = append(batches, names[i:end])
+	}
+
+	jobs := make(chan []string, len(batches))
+	for _, b := range batches {
+		jobs <- b
+	}
+	close(jobs)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+
+	workers := updateCheckWorkers
+	if workers > len(batches) {
+		workers = len(batches)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range jobs {
+				versions, err := u.queryInfo(batch)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				for name, version := range versions {
+					result[name] = version
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return result, firstErr
+}
+
+func (u *UpdateChecker) queryInfo(names []string) (map[string]string, error) {
+	results, err := aur.Query(u.AURURL, names)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string, len(results))
+	for _, r := range results {
+		out[r.Name] = r.Version
+	}
+	return out, nil
+}
+
+func (u *UpdateChecker) loadCache() map[string]cacheEntry {
+	cache := make(map[string]cacheEntry)
+	f, err := os.Open(u.CachePath)
+	if err != nil {
+		return cache
+	}
+	defer f.Close()
+
+	json.NewDecoder(f).Decode(&cache)
+	return cache
+}
+
+func (u *UpdateChecker) saveCache(cache map[string]cacheEntry) {
+	if err := os.MkdirAll(path.Dir(u.CachePath), 0755); err != nil {
+		return
+	}
+	f, err := os.Create(u.CachePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	json.NewEncoder(f).Encode(cache)
+}
+
+// getVersionDiff walks both version strings rune by rune and returns the
+// trailing substrings starting at the first differing rune, so that only
+// the changed portion of a version needs to be highlighted. Comparing by
+// byte instead would risk splitting a multibyte rune in two, corrupting
+// both halves.
+func getVersionDiff(left, right string) (string, string) {
+	if left == right {
+		return "", ""
+	}
+
+	l, r := []rune(left), []rune(right)
+	i := 0
+	for i < len(l) && i < len(r) && l[i] == r[i] {
+		i++
+	}
+
+	return string(l[i:]), string(r[i:])
+}
+
+// vercmp compares two pacman package version strings ("[epoch:]pkgver-
+// pkgrel") and returns -1, 0, or 1 depending on whether a is older than,
+// equal to, or newer than b, following the same epoch/pkgver/pkgrel
+// precedence and alternating alpha/numeric segment comparison as
+// pacman's own vercmp(8). When one version only has extra trailing
+// characters, it ranks newer (1.0a against 1.0 gives 1), as in RPM;
+// pacman's vercmp(8) ranks a trailing run of letters older.
+func vercmp(a, b string) int {
+	aEpoch, aRest := splitEpoch(a)
+	bEpoch, bRest := splitEpoch(b)
+	if aEpoch != bEpoch {
+		if aEpoch < bEpoch {
+			return -1
+		}
+		return 1
+	}
+
+	aVer, aRel := splitPkgrel(aRest)
+	bVer, bRel := splitPkgrel(bRest)
+	if c := rpmvercmp(aVer, bVer); c != 0 {
+		return c
+	}
+	return rpmvercmp(aRel, bRel)
+}
+
+// splitEpoch splits off a leading "N:" epoch, defaulting to 0 if absent.
+func splitEpoch(v string) (int, string) {
+	if i := strings.IndexByte(v, ':'); i >= 0 {
+		epoch, err := strconv.Atoi(v[:i])
+		if err == nil {
+			return epoch, v[i+1:]
+		}
+	}
+	return 0, v
+}
+
+// splitPkgrel splits "pkgver-pkgrel" on the last "-"; if there is none,
+// pkgrel is empty and sorts before any real pkgrel.
+func splitPkgrel(v string) (string, string) {
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		return v[:i], v[i+1:]
+	}
+	return v, ""
+}
+
+// rpmvercmp implements RPM/pacman's version-segment comparison: strings
+// are walked in alternating runs of digits and letters, numeric runs
+// always outrank alphabetic ones, numeric runs compare as integers
+// (ignoring leading zeros), and a "~" sorts before everything, including
+// the empty string, so that pre-release suffixes like "1.0~beta" are
+// older than the final "1.0".
+func rpmvercmp(a, b string) int {
+	for len(a) > 0 || len(b) > 0 {
+		// Drop separators first, so that the kind of the next run is
+		// decided by its first digit or letter.
+		for len(a) > 0 && a[0] != '~' && !isDigit(a[0]) && !isAlpha(a[0]) {
+			a = a[1:]
+		}
+		for len(b) > 0 && b[0] != '~' && !isDigit(b[0]) && !isAlpha(b[0]) {
+			b = b[1:]
+		}
+		for (len(a) > 0 && a[0] == '~') || (len(b) > 0 && b[0] == '~') {
+			switch {
+			case len(a) > 0 && a[0] == '~' && len(b) > 0 && b[0] == '~':
+				a, b = a[1:], b[1:]
+			case len(a) > 0 && a[0] == '~':
+				return -1
+			default:
+				return 1
+			}
+		}
+		if len(a) == 0 || len(b) == 0 {
+			break
+		}
+
+		aNum := isDigit(a[0])
+		aSeg, aRest := takeSegment(a, aNum)
+		bSeg, bRest := takeSegment(b, aNum)
+
+		// A segment kind mismatch (e.g. "" vs letters) means one side
+		// ran out of that kind of run; numeric always wins.
+		if bSeg == "" {
+			if aNum {
+				return 1
+			}
+			return -1
+		}
+
+		var c int
+		if aNum {
+			c = compareNumeric(aSeg, bSeg)
+		} else {
+			c = strings.Compare(aSeg, bSeg)
+		}
+		if c != 0 {
+			if c < 0 {
+				return -1
+			}
+			return 1
+		}
+
+		a, b = aRest, bRest
+	}
+
+	switch {
+	case len(a) == len(b):
+		return 0
+	case len(a) > 0:
+		return 1
+	default:
+		return -1
+	}
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// takeSegment consumes a leading run of digits (if wantDigits) or
+// letters from s, returning the run and the remainder. Any other
+// separator byte at the front is skipped first.
+func takeSegment(s string, wantDigits bool) (string, string) {
+	for len(s) > 0 && !isDigit(s[0]) && !isAlpha(s[0]) {
+		s = s[1:]
+	}
+
+	i := 0
+	for i < len(s) && isDigit(s[i]) == wantDigits && (isDigit(s[i]) || isAlpha(s[i])) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isAlpha(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+// compareNumeric compares two digit runs as integers, ignoring leading
+// zeros, without risking overflow on arbitrarily long version numbers.
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}